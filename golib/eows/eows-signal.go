@@ -44,5 +44,18 @@ func (e *ExecOverWS) Signal(signal string) error {
 	}
 
 	e.logDebug("SEND signal %v to proc %v", sig, e.proc.Pid)
+
+	// When the process is the leader of its own process group - as it
+	// would be when started under a PTY session, see StartPTY - deliver
+	// the signal to the whole group so it behaves like Ctrl-C in an
+	// interactive terminal instead of only reaching the shell and leaving
+	// orphaned children behind. This never actually triggers yet: nothing
+	// calls StartPTY until ExecOverWS.Start is wired up to it (see the
+	// PTYSession doc comment in pty.go).
+	if pgid, err := syscall.Getpgid(e.proc.Pid); err == nil && pgid == e.proc.Pid {
+		if ssig, ok := sig.(syscall.Signal); ok {
+			return syscall.Kill(-pgid, ssig)
+		}
+	}
 	return e.proc.Signal(sig)
 }
@@ -0,0 +1,18 @@
+// +build windows
+
+package eows
+
+import "syscall"
+
+// SysProcAttrForNewProcessGroup returns the SysProcAttr that the command
+// spawned by ExecOverWS must use on Windows so that CTRL_BREAK_EVENT (sent
+// by Signal) is deliverable to it: the child needs to be the root of its
+// own process group, via CREATE_NEW_PROCESS_GROUP.
+//
+// Nothing in this package calls this yet: the process spawn itself lives in
+// ExecOverWS.Start (execws.go), which is outside this snapshot of the
+// module. Until that call sets Cmd.SysProcAttr to this value, CTRL_BREAK
+// will never reach the child and Signal's Windows path is inert.
+func SysProcAttrForNewProcessGroup() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
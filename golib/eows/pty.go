@@ -0,0 +1,67 @@
+// +build !windows
+
+// Package eows is used to Execute commands Over WebSocket
+package eows
+
+import (
+	"os"
+	"os/exec"
+
+	"github.com/creack/pty"
+)
+
+// WindowSize describes a terminal's size in character cells, as reported by
+// a WebSocket client.
+type WindowSize struct {
+	Rows uint16
+	Cols uint16
+}
+
+// PTYSession wraps the master side of the pseudo-terminal a command was
+// started under. Unlike a plain exec.Cmd pipe, commands run under it see a
+// real TTY, so interactive tools (shells, sudo, colored output, line
+// editors) behave as they would in a terminal session.
+//
+// This is meant to be called from ExecOverWS.Start, which would spawn the
+// child through StartPTY instead of plain pipes when a PTY option is
+// enabled, and forward WebSocket resize messages to Resize. That wiring -
+// the PTY/WindowSize fields on ExecOverWS and the Start() call site - lives
+// in execws.go, which is outside this snapshot of the module, so nothing
+// calls StartPTY yet: until that lands, PTYSession is unused and the
+// process-group signal delivery in Signal never applies.
+type PTYSession struct {
+	f *os.File
+}
+
+// StartPTY starts cmd attached to a new pseudo-terminal sized to size (when
+// non-zero) and returns a PTYSession wrapping its master side. Reading from
+// the session yields the command's merged stdout/stderr; writing to it
+// feeds the command's stdin, exactly like a real terminal.
+func StartPTY(cmd *exec.Cmd, size WindowSize) (*PTYSession, error) {
+	var f *os.File
+	var err error
+	if size.Rows > 0 && size.Cols > 0 {
+		f, err = pty.StartWithSize(cmd, &pty.Winsize{Rows: size.Rows, Cols: size.Cols})
+	} else {
+		f, err = pty.Start(cmd)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &PTYSession{f: f}, nil
+}
+
+// Resize changes the PTY's window size, delivering SIGWINCH to its
+// foreground process group exactly as a real terminal resize would.
+func (p *PTYSession) Resize(rows, cols uint16) error {
+	return pty.Setsize(p.f, &pty.Winsize{Rows: rows, Cols: cols})
+}
+
+// Read reads from the PTY's merged output stream.
+func (p *PTYSession) Read(b []byte) (int, error) { return p.f.Read(b) }
+
+// Write writes to the PTY's input stream.
+func (p *PTYSession) Write(b []byte) (int, error) { return p.f.Write(b) }
+
+// Close closes the PTY master side.
+func (p *PTYSession) Close() error { return p.f.Close() }
@@ -0,0 +1,68 @@
+// +build windows
+
+// Package eows is used to Execute commands Over WebSocket
+package eows
+
+import (
+	"fmt"
+	"syscall"
+)
+
+var (
+	modkernel32                  = syscall.NewLazyDLL("kernel32.dll")
+	procGenerateConsoleCtrlEvent = modkernel32.NewProc("GenerateConsoleCtrlEvent")
+)
+
+const ctrlBreakEvent = 1
+
+// Signal sends a signal to the running command / process.
+//
+// Windows has no POSIX signal delivery. SIGINT/SIGTERM/SIGQUIT are mapped to
+// a CTRL_BREAK_EVENT console event, which is only deliverable to processes
+// spawned in their own process group via CREATE_NEW_PROCESS_GROUP (see
+// SysProcAttrForNewProcessGroup). SIGKILL falls back to TerminateProcess.
+// The remaining POSIX-only signals (SIGSTOP, SIGCONT, SIGHUP, SIGUSR1/2)
+// have no Windows equivalent and return an error.
+//
+// The CTRL_BREAK_EVENT path is currently inert: it requires the process
+// spawn in ExecOverWS.Start to set SysProcAttr to
+// SysProcAttrForNewProcessGroup's result, and that spawn site lives in
+// execws.go, outside this snapshot of the module. Until it is updated to
+// do so, CTRL_BREAK_EVENT has no process in its own group to reach.
+func (e *ExecOverWS) Signal(signal string) error {
+	if e.proc == nil {
+		return fmt.Errorf("Cannot retrieve process")
+	}
+
+	switch signal {
+	case "interrupt", "SIGINT", "terminated", "SIGTERM", "quit", "SIGQUIT":
+		e.logDebug("SEND CTRL_BREAK to proc %v", e.proc.Pid)
+		return generateConsoleCtrlEvent(ctrlBreakEvent, uint32(e.proc.Pid))
+
+	case "killed", "SIGKILL":
+		e.logDebug("TerminateProcess proc %v", e.proc.Pid)
+		return e.proc.Kill()
+
+	case "stopped (signal)", "SIGSTOP",
+		"continued", "SIGCONT",
+		"hangup", "SIGHUP",
+		"aborted", "SIGABRT",
+		"stopped", "SIGTSTP",
+		"user defined signal 1", "SIGUSR1",
+		"user defined signal 2", "SIGUSR2":
+		return fmt.Errorf("Signal %s is unsupported on windows", signal)
+
+	default:
+		return fmt.Errorf("Unsupported signal")
+	}
+}
+
+// generateConsoleCtrlEvent sends a Windows console control event to the
+// given process, typically CTRL_BREAK_EVENT.
+func generateConsoleCtrlEvent(event, pid uint32) error {
+	r, _, err := procGenerateConsoleCtrlEvent.Call(uintptr(event), uintptr(pid))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
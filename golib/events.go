@@ -0,0 +1,173 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Events related defaults
+const (
+	eventsMinBackoff = 500 * time.Millisecond
+	eventsMaxBackoff = 30 * time.Second
+)
+
+// RawEvent is a single entry of the long-poll events stream, as decoded
+// straight from the JSON array returned by the "/rest/events"-like endpoint.
+type RawEvent struct {
+	ID   int64           `json:"id"`
+	Type string          `json:"type"`
+	Time time.Time       `json:"time"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Event is a RawEvent with its Data field already decoded.
+type Event struct {
+	ID   int64
+	Type string
+	Time time.Time
+	Data interface{}
+}
+
+// Events issues repeated long-poll GETs against path (inspired by syncthing's
+// "/rest/events" endpoint), starting right after sinceID, and streams decoded
+// events on the returned channel. filter, when not empty, restricts the
+// subscription to the given event type names.
+//
+// The long-poll is re-issued for as long as ctx is not canceled. Transient
+// errors (network failures, decode errors) are pushed to the error channel
+// and followed by an exponential backoff before reconnecting; the cursor is
+// only advanced past events that were successfully decoded. Both channels
+// are closed once ctx is done.
+func (c *HTTPClient) Events(ctx context.Context, path string, sinceID int64, filter []string) (<-chan RawEvent, <-chan error) {
+	events := make(chan RawEvent)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(events)
+		defer close(errs)
+
+		since := sinceID
+		attempt := 0
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+
+			var data []byte
+			_, err := c._HTTPRequest(ctx, "GET", c.eventsURL(path, since, filter), nil, &data)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				if !c.pushEventsErr(ctx, errs, err) {
+					return
+				}
+				attempt++
+				if !c.eventsBackoff(ctx, attempt) {
+					return
+				}
+				continue
+			}
+			attempt = 0
+
+			var batch []RawEvent
+			if err := json.Unmarshal(data, &batch); err != nil {
+				if !c.pushEventsErr(ctx, errs, err) {
+					return
+				}
+				attempt++
+				if !c.eventsBackoff(ctx, attempt) {
+					return
+				}
+				continue
+			}
+
+			for _, ev := range batch {
+				select {
+				case events <- ev:
+					since = ev.ID
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return events, errs
+}
+
+// Subscribe is a convenience wrapper around Events that decodes each event's
+// Data field and invokes handler for it. It blocks until ctx is canceled.
+func (c *HTTPClient) Subscribe(ctx context.Context, eventTypes []string, handler func(Event)) error {
+	events, errs := c.Events(ctx, "events", 0, eventTypes)
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return nil
+			}
+			var data interface{}
+			if len(ev.Data) > 0 {
+				if err := json.Unmarshal(ev.Data, &data); err != nil {
+					c.log(HTTPLogLevelWarning, "Subscribe: cannot decode event %d data: %v", ev.ID, err)
+					continue
+				}
+			}
+			handler(Event{ID: ev.ID, Type: ev.Type, Time: ev.Time, Data: data})
+		case err, ok := <-errs:
+			if ok && err != nil {
+				c.log(HTTPLogLevelWarning, "Subscribe: events error: %v", err)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// eventsURL appends the since cursor and optional event type filter to path.
+func (c *HTTPClient) eventsURL(path string, since int64, filter []string) string {
+	url := path
+	sep := "?"
+	if strings.Contains(url, "?") {
+		sep = "&"
+	}
+	url += fmt.Sprintf("%ssince=%d", sep, since)
+	if len(filter) > 0 {
+		url += "&events=" + strings.Join(filter, ",")
+	}
+	return url
+}
+
+// pushEventsErr forwards err on errs unless ctx is done first. It returns
+// false when ctx is done, signaling the caller to give up.
+func (c *HTTPClient) pushEventsErr(ctx context.Context, errs chan<- error, err error) bool {
+	select {
+	case errs <- err:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// eventsBackoff sleeps an exponentially increasing delay (capped at
+// eventsMaxBackoff) before the next reconnect attempt. It returns false if
+// ctx is canceled while waiting.
+func (c *HTTPClient) eventsBackoff(ctx context.Context, attempt int) bool {
+	d := eventsMinBackoff * time.Duration(int64(1)<<uint(attempt-1))
+	if d <= 0 || d > eventsMaxBackoff {
+		d = eventsMaxBackoff
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
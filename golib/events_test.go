@@ -0,0 +1,136 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEventsURL(t *testing.T) {
+	cases := []struct {
+		name   string
+		path   string
+		since  int64
+		filter []string
+		want   string
+	}{
+		{"no filter", "events", 0, nil, "events?since=0"},
+		{"with filter", "events", 42, []string{"A", "B"}, "events?since=42&events=A,B"},
+		{"path already has a query", "events?foo=bar", 7, nil, "events?foo=bar&since=7"},
+	}
+
+	c := &HTTPClient{}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := c.eventsURL(tc.path, tc.since, tc.filter); got != tc.want {
+				t.Errorf("eventsURL(%q, %d, %v) = %q, want %q", tc.path, tc.since, tc.filter, got, tc.want)
+			}
+		})
+	}
+}
+
+// TestEventsAdvancesCursorAndRecoversFromMalformedBatch drives Events
+// against a stub long-poll server that returns a malformed batch once, to
+// exercise both the reconnect/backoff state machine and cursor advancement
+// together.
+func TestEventsAdvancesCursorAndRecoversFromMalformedBatch(t *testing.T) {
+	var calls int32
+	var sinceAfterMalformed atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		if n == 3 {
+			sinceAfterMalformed.Store(r.URL.Query().Get("since"))
+		}
+		switch n {
+		case 1:
+			fmt.Fprint(w, `[{"id":1,"type":"demo","data":{"n":1}}]`)
+		case 2:
+			// Malformed on purpose: must trigger a backoff + error, not a
+			// busy loop, and must not advance the cursor.
+			fmt.Fprint(w, `not json`)
+		default:
+			fmt.Fprint(w, `[{"id":2,"type":"demo","data":{"n":2}}]`)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := HTTPNewClient(srv.URL, HTTPClientConfig{Apikey: "test-key"})
+	if err != nil {
+		t.Fatalf("HTTPNewClient: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events, errs := client.Events(ctx, "events", 0, nil)
+
+	var gotIDs []int64
+	var gotErr bool
+readLoop:
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				break readLoop
+			}
+			gotIDs = append(gotIDs, ev.ID)
+			if ev.ID == 2 {
+				cancel()
+			}
+		case e, ok := <-errs:
+			if ok && e != nil {
+				gotErr = true
+			}
+		case <-ctx.Done():
+			break readLoop
+		}
+	}
+
+	if !gotErr {
+		t.Error("expected the malformed batch to surface an error on the error channel")
+	}
+	if len(gotIDs) < 2 || gotIDs[0] != 1 || gotIDs[len(gotIDs)-1] != 2 {
+		t.Errorf("got event IDs %v, want a sequence starting at 1 and ending at 2", gotIDs)
+	}
+	if got, _ := sinceAfterMalformed.Load().(string); got != "1" {
+		t.Errorf("request after the malformed batch used since=%q, want %q", got, "1")
+	}
+}
+
+func TestEventsStopsOnContextCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[]`)
+	}))
+	defer srv.Close()
+
+	client, err := HTTPNewClient(srv.URL, HTTPClientConfig{Apikey: "test-key"})
+	if err != nil {
+		t.Fatalf("HTTPNewClient: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, errs := client.Events(ctx, "events", 0, nil)
+	cancel()
+
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("events channel should be closed once ctx is canceled")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the events channel to close")
+	}
+
+	select {
+	case _, ok := <-errs:
+		if ok {
+			t.Error("errs channel should be closed once ctx is canceled")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the errs channel to close")
+	}
+}
@@ -0,0 +1,100 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// HTTPError is returned by _HTTPRequest/_Request whenever the server answers
+// with a non-200 status. It carries enough of the raw response for callers
+// to branch on the cause instead of parsing the error string, including the
+// decoded "error" field of the API's `{"status":"error","error":"..."}` JSON
+// envelope, when present.
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	Body       []byte
+	APIError   string
+	Header     http.Header
+
+	csrf bool // true when this 403 was caused by a missing/expired CSRF token
+}
+
+// Error implements the error interface.
+func (e *HTTPError) Error() string {
+	if e.APIError != "" {
+		return e.APIError
+	}
+	if body := strings.TrimSpace(string(e.Body)); body != "" {
+		return body
+	}
+	return "HTTP status " + e.Status
+}
+
+// Sentinel errors usable with errors.Is against an *HTTPError.
+var (
+	// ErrCSRF matches a 403 response caused by a missing/expired CSRF token.
+	ErrCSRF = errors.New("invalid CSRF token")
+	// ErrUnauthorized matches a 401 response.
+	ErrUnauthorized = errors.New("invalid username or password")
+	// ErrNotFound matches a 404 response.
+	ErrNotFound = errors.New("invalid endpoint or API call")
+)
+
+// Is reports whether e matches target, one of the sentinel errors above, so
+// callers can write errors.Is(err, ErrNotFound).
+func (e *HTTPError) Is(target error) bool {
+	switch target {
+	case ErrCSRF:
+		return e.csrf
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	}
+	return false
+}
+
+// IsCSRFError reports whether err is an *HTTPError caused by an invalid or
+// expired CSRF token.
+func IsCSRFError(err error) bool {
+	return errors.Is(err, ErrCSRF)
+}
+
+// IsUnauthorized reports whether err is an *HTTPError carrying a 401 status.
+func IsUnauthorized(err error) bool {
+	return errors.Is(err, ErrUnauthorized)
+}
+
+// IsNotFound reports whether err is an *HTTPError carrying a 404 status.
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// newHTTPError builds an *HTTPError from response, draining and closing its
+// body in the process. csrf marks a 403 known to be caused by a CSRF issue
+// (as opposed to an invalid API key).
+func (c *HTTPClient) newHTTPError(response *http.Response, csrf bool) *HTTPError {
+	body := c.ResponseToBArray(response)
+
+	apiErr := ""
+	data := make(map[string]interface{})
+	if json.Unmarshal(body, &data) == nil {
+		if msg, found := data["error"]; found {
+			if s, ok := msg.(string); ok {
+				apiErr = s
+			}
+		}
+	}
+
+	return &HTTPError{
+		StatusCode: response.StatusCode,
+		Status:     response.Status,
+		Body:       body,
+		APIError:   apiErr,
+		Header:     response.Header,
+		csrf:       csrf,
+	}
+}
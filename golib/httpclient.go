@@ -2,15 +2,21 @@ package common
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
+	"math/rand"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // HTTPClient .
@@ -28,6 +34,11 @@ type HTTPClient struct {
 	id         string
 	csrf       string
 	conf       HTTPClientConfig
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	limiter *rateLimiter
 }
 
 // HTTPClientConfig is used to config HTTPClient
@@ -40,6 +51,33 @@ type HTTPClientConfig struct {
 	LogOut              io.Writer
 	LogLevel            int
 	LogPrefix           string
+
+	// Timeout is the default per-request timeout applied to requests made
+	// through Get/Post/Put/Delete and their HTTP* low-level counterparts,
+	// i.e. calls that don't receive a context from the caller. It has no
+	// effect on the *WithContext variants or on Events: a context passed
+	// in explicitly, even a bare context.Background(), is never bounded by
+	// Timeout, since the caller is trusted to manage its own deadline.
+	// Zero means no default timeout.
+	Timeout time.Duration
+
+	// MaxRetries is the number of additional attempts made after a request
+	// fails according to RetryOn. Zero disables retries.
+	MaxRetries int
+	// RetryBackoff returns how long to wait before retry attempt n
+	// (n starts at 1). Defaults to an exponential backoff with jitter.
+	RetryBackoff func(attempt int) time.Duration
+	// RetryOn decides whether a given response/error pair should be
+	// retried. Defaults to retrying connection errors, 5xx and 429
+	// (honoring the Retry-After header on the latter).
+	RetryOn func(res *http.Response, err error) bool
+
+	// RequestsPerSecond throttles outgoing requests to a token bucket of
+	// this rate. Zero disables rate limiting.
+	RequestsPerSecond float64
+	// Burst is the token bucket size. Defaults to RequestsPerSecond (but
+	// never less than 1) when left at zero.
+	Burst int
 }
 
 // Logger levels constants
@@ -71,6 +109,8 @@ func HTTPNewClient(baseURL string, cfg HTTPClientConfig) (*HTTPClient, error) {
 	if cfg.LogOut == nil {
 		lOut = os.Stdout
 	}
+
+	ctx, cancel := context.WithCancel(context.Background())
 	client := HTTPClient{
 		LoggerOut:    lOut,
 		LoggerLevel:  cfg.LogLevel,
@@ -81,6 +121,9 @@ func HTTPNewClient(baseURL string, cfg HTTPClientConfig) (*HTTPClient, error) {
 		endpoint:   baseURL,
 		apikey:     cfg.Apikey,
 		conf:       cfg,
+		ctx:        ctx,
+		cancel:     cancel,
+		limiter:    newRateLimiter(cfg.RequestsPerSecond, cfg.Burst),
 		/* TODO - add user + pwd support
 		username:   c.GlobalString("username"),
 		password:   c.GlobalString("password"),
@@ -143,28 +186,99 @@ func (c *HTTPClient) GetClientID() string {
 	return c.id
 }
 
+// Close cancels any in-flight request issued by this client and shuts down
+// the underlying transport's idle connections. The client must not be used
+// after Close is called.
+func (c *HTTPClient) Close() error {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	if t, ok := c.httpClient.Transport.(*http.Transport); ok {
+		t.CloseIdleConnections()
+	}
+	return nil
+}
+
+// withTimeout returns ctx (defaulting to the client's base context, then to
+// context.Background()) wrapped with the configured default Timeout, if
+// any. The default Timeout is only applied when the caller didn't supply
+// its own context: callers that pass one in explicitly (GetWithContext and
+// friends, the Events long-poll) are trusted to manage their own deadline,
+// since the default Timeout is sized for ordinary request/response calls
+// and would otherwise cut long-lived requests short. An explicit ctx is
+// still canceled along with the client's base context, so Close aborts it
+// too. The returned cancel func is always safe to call and must be.
+func (c *HTTPClient) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	explicit := ctx != nil
+	if ctx == nil {
+		ctx = c.ctx
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if explicit && c.ctx != nil {
+		merged, cancel := context.WithCancel(ctx)
+		go func() {
+			select {
+			case <-c.ctx.Done():
+				cancel()
+			case <-merged.Done():
+			}
+		}()
+		return merged, cancel
+	}
+	if !explicit && c.conf.Timeout > 0 {
+		return context.WithTimeout(ctx, c.conf.Timeout)
+	}
+	return ctx, func() {}
+}
+
 /***
 ** High level functions
 ***/
 
 // Get Send a Get request to client and return directly data of body response
 func (c *HTTPClient) Get(url string, out interface{}) error {
-	return c._Request("GET", url, nil, out)
+	return c._Request(nil, "GET", url, nil, out)
+}
+
+// GetWithContext is the context-aware variant of Get. The request is
+// aborted as soon as ctx is canceled or its deadline expires.
+func (c *HTTPClient) GetWithContext(ctx context.Context, url string, out interface{}) error {
+	return c._Request(ctx, "GET", url, nil, out)
 }
 
 // Post Send a Post request to client and return directly data of body response
 func (c *HTTPClient) Post(url string, in interface{}, out interface{}) error {
-	return c._Request("POST", url, in, out)
+	return c._Request(nil, "POST", url, in, out)
+}
+
+// PostWithContext is the context-aware variant of Post. The request is
+// aborted as soon as ctx is canceled or its deadline expires.
+func (c *HTTPClient) PostWithContext(ctx context.Context, url string, in interface{}, out interface{}) error {
+	return c._Request(ctx, "POST", url, in, out)
 }
 
 // Put Send a Put request to client and return directly data of body response
 func (c *HTTPClient) Put(url string, in interface{}, out interface{}) error {
-	return c._Request("PUT", url, in, out)
+	return c._Request(nil, "PUT", url, in, out)
+}
+
+// PutWithContext is the context-aware variant of Put. The request is
+// aborted as soon as ctx is canceled or its deadline expires.
+func (c *HTTPClient) PutWithContext(ctx context.Context, url string, in interface{}, out interface{}) error {
+	return c._Request(ctx, "PUT", url, in, out)
 }
 
 // Delete Send a Delete request to client and return directly data of body response
 func (c *HTTPClient) Delete(url string, out interface{}) error {
-	return c._Request("DELETE", url, nil, out)
+	return c._Request(nil, "DELETE", url, nil, out)
+}
+
+// DeleteWithContext is the context-aware variant of Delete. The request is
+// aborted as soon as ctx is canceled or its deadline expires.
+func (c *HTTPClient) DeleteWithContext(ctx context.Context, url string, out interface{}) error {
+	return c._Request(ctx, "DELETE", url, nil, out)
 }
 
 /***
@@ -173,46 +287,46 @@ func (c *HTTPClient) Delete(url string, out interface{}) error {
 
 // HTTPGet Send a Get request to client and return an error object
 func (c *HTTPClient) HTTPGet(url string, data *[]byte) error {
-	_, err := c._HTTPRequest("GET", url, nil, data)
+	_, err := c._HTTPRequest(nil, "GET", url, nil, data)
 	return err
 }
 
 // HTTPGetWithRes Send a Get request to client and return both response and error
 func (c *HTTPClient) HTTPGetWithRes(url string, data *[]byte) (*http.Response, error) {
-	return c._HTTPRequest("GET", url, nil, data)
+	return c._HTTPRequest(nil, "GET", url, nil, data)
 }
 
 // HTTPPost Send a POST request to client and return an error object
 func (c *HTTPClient) HTTPPost(url string, body string) error {
-	_, err := c._HTTPRequest("POST", url, &body, nil)
+	_, err := c._HTTPRequest(nil, "POST", url, &body, nil)
 	return err
 }
 
 // HTTPPostWithRes Send a POST request to client and return both response and error
 func (c *HTTPClient) HTTPPostWithRes(url string, body string) (*http.Response, error) {
-	return c._HTTPRequest("POST", url, &body, nil)
+	return c._HTTPRequest(nil, "POST", url, &body, nil)
 }
 
 // HTTPPut Send a PUT request to client and return an error object
 func (c *HTTPClient) HTTPPut(url string, body string) error {
-	_, err := c._HTTPRequest("PUT", url, &body, nil)
+	_, err := c._HTTPRequest(nil, "PUT", url, &body, nil)
 	return err
 }
 
 // HTTPPutWithRes Send a PUT request to client and return both response and error
 func (c *HTTPClient) HTTPPutWithRes(url string, body string) (*http.Response, error) {
-	return c._HTTPRequest("PUT", url, &body, nil)
+	return c._HTTPRequest(nil, "PUT", url, &body, nil)
 }
 
 // HTTPDelete Send a DELETE request to client and return an error object
 func (c *HTTPClient) HTTPDelete(url string) error {
-	_, err := c._HTTPRequest("DELETE", url, nil, nil)
+	_, err := c._HTTPRequest(nil, "DELETE", url, nil, nil)
 	return err
 }
 
 // HTTPDeleteWithRes Send a DELETE request to client and return both response and error
 func (c *HTTPClient) HTTPDeleteWithRes(url string) (*http.Response, error) {
-	return c._HTTPRequest("DELETE", url, nil, nil)
+	return c._HTTPRequest(nil, "DELETE", url, nil, nil)
 }
 
 // ResponseToBArray converts an Http response to a byte array
@@ -230,7 +344,7 @@ func (c *HTTPClient) ResponseToBArray(response *http.Response) []byte {
 ***/
 
 // _HTTPRequest Generic function used by high level function to send requests
-func (c *HTTPClient) _Request(method string, url string, in interface{}, out interface{}) error {
+func (c *HTTPClient) _Request(ctx context.Context, method string, url string, in interface{}, out interface{}) error {
 	var err error
 	var res *http.Response
 	var body []byte
@@ -240,16 +354,13 @@ func (c *HTTPClient) _Request(method string, url string, in interface{}, out int
 			return err
 		}
 		sb := string(body)
-		res, err = c._HTTPRequest(method, url, &sb, nil)
+		res, err = c._HTTPRequest(ctx, method, url, &sb, nil)
 	} else {
-		res, err = c._HTTPRequest(method, url, nil, nil)
+		res, err = c._HTTPRequest(ctx, method, url, nil, nil)
 	}
 	if err != nil {
 		return err
 	}
-	if res.StatusCode != 200 {
-		return fmt.Errorf("HTTP status %s", res.Status)
-	}
 
 	// Don't decode response if no out data pointer is nil
 	if out == nil {
@@ -259,19 +370,23 @@ func (c *HTTPClient) _Request(method string, url string, in interface{}, out int
 }
 
 // _HTTPRequest Generic function that returns a new Request given a method, URL, and optional body and data.
-func (c *HTTPClient) _HTTPRequest(method, url string, body *string, data *[]byte) (*http.Response, error) {
+func (c *HTTPClient) _HTTPRequest(ctx context.Context, method, url string, body *string, data *[]byte) (*http.Response, error) {
 	if !c.initDone {
 		if err := c.getCidAndCsrf(); err == nil {
 			c.initDone = true
 		}
 	}
 
+	var cancel context.CancelFunc
+	ctx, cancel = c.withTimeout(ctx)
+	defer cancel()
+
 	var err error
 	var request *http.Request
 	if body != nil {
-		request, err = http.NewRequest(method, c.formatURL(url), bytes.NewBufferString(*body))
+		request, err = http.NewRequestWithContext(ctx, method, c.formatURL(url), bytes.NewBufferString(*body))
 	} else {
-		request, err = http.NewRequest(method, c.formatURL(url), nil)
+		request, err = http.NewRequestWithContext(ctx, method, c.formatURL(url), nil)
 	}
 
 	if err != nil {
@@ -281,18 +396,76 @@ func (c *HTTPClient) _HTTPRequest(method, url string, body *string, data *[]byte
 	if err != nil {
 		return res, err
 	}
-	if res.StatusCode != 200 {
-		return res, errors.New(res.Status)
-	}
 
 	if data != nil {
 		*data = c.ResponseToBArray(res)
+	} else {
+		// ctx is canceled as soon as this function returns: read the body
+		// now and hand the caller an in-memory copy of it.
+		res.Body = ioutil.NopCloser(bytes.NewReader(c.ResponseToBArray(res)))
 	}
 
 	return res, nil
 }
 
+// handleRequest sends request, applying the configured rate limit and retry
+// policy around the actual transport round-trip done by doRequestOnce.
 func (c *HTTPClient) handleRequest(request *http.Request) (*http.Response, error) {
+	if err := c.limiter.wait(request.Context()); err != nil {
+		return nil, err
+	}
+
+	retryOn := c.conf.RetryOn
+	if retryOn == nil {
+		retryOn = defaultRetryOn
+	}
+	backoff := c.conf.RetryBackoff
+	if backoff == nil {
+		backoff = defaultRetryBackoff
+	}
+
+	req := request
+	for attempt := 0; ; attempt++ {
+		res, err := c.doRequestOnce(req)
+		if attempt >= c.conf.MaxRetries || !retryOn(res, err) {
+			return res, err
+		}
+
+		wait := backoff(attempt + 1)
+		if res != nil {
+			if res.StatusCode == http.StatusTooManyRequests {
+				if ra := parseRetryAfter(res.Header.Get("Retry-After")); ra > 0 {
+					wait = ra
+				}
+			}
+			// Drain and close the body of the response we are about to discard.
+			c.ResponseToBArray(res)
+		} else if herr, ok := err.(*HTTPError); ok && herr.StatusCode == http.StatusTooManyRequests {
+			if ra := parseRetryAfter(herr.Header.Get("Retry-After")); ra > 0 {
+				wait = ra
+			}
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-request.Context().Done():
+			return nil, request.Context().Err()
+		}
+
+		req = request.Clone(request.Context())
+		if request.GetBody != nil {
+			body, gerr := request.GetBody()
+			if gerr != nil {
+				return res, err
+			}
+			req.Body = body
+		}
+	}
+}
+
+// doRequestOnce performs a single HTTP round-trip: it sets auth/CSRF headers,
+// issues the request and translates the response status into an error.
+func (c *HTTPClient) doRequestOnce(request *http.Request) (*http.Response, error) {
 	if c.conf.HeaderAPIKeyName != "" && c.apikey != "" {
 		request.Header.Set(c.conf.HeaderAPIKeyName, c.apikey)
 	}
@@ -330,29 +503,12 @@ func (c *HTTPClient) handleRequest(request *http.Request) (*http.Response, error
 	// OK CSRF found
 csrffound:
 
-	if response.StatusCode == 404 {
-		return nil, errors.New("Invalid endpoint or API call")
-	} else if response.StatusCode == 401 {
-		return nil, errors.New("Invalid username or password")
-	} else if response.StatusCode == 403 {
-		if c.apikey == "" {
-			// Request a new Csrf for next requests
-			c.getCidAndCsrf()
-			return nil, errors.New("Invalid CSRF token")
-		}
-		return nil, errors.New("Invalid API key")
-	} else if response.StatusCode != 200 {
-		data := make(map[string]interface{})
-		// Try to decode error field of APIError struct
-		json.Unmarshal(c.ResponseToBArray(response), &data)
-		if err, found := data["error"]; found {
-			return nil, fmt.Errorf(err.(string))
-		}
-		body := strings.TrimSpace(string(c.ResponseToBArray(response)))
-		if body != "" {
-			return nil, fmt.Errorf(body)
-		}
-		return nil, errors.New("Unknown HTTP status returned: " + response.Status)
+	if response.StatusCode == http.StatusForbidden && c.apikey == "" {
+		// Request a new Csrf for next requests
+		c.getCidAndCsrf()
+		return nil, c.newHTTPError(response, true)
+	} else if response.StatusCode != http.StatusOK {
+		return nil, c.newHTTPError(response, false)
 	}
 	return response, nil
 }
@@ -376,7 +532,9 @@ func (c *HTTPClient) getCidAndCsrf() error {
 	if c.apikey != "" {
 		return nil
 	}
-	request, err := http.NewRequest("GET", c.endpoint, nil)
+	ctx, cancel := c.withTimeout(nil)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, "GET", c.endpoint, nil)
 	if err != nil {
 		return err
 	}
@@ -392,6 +550,109 @@ func (c *HTTPClient) getCidAndCsrf() error {
 	return nil
 }
 
+// defaultRetryBackoff is the default RetryBackoff: exponential growth from
+// 200ms, capped at 10s, with up to 50% jitter to avoid retry storms.
+func defaultRetryBackoff(attempt int) time.Duration {
+	const base = 200 * time.Millisecond
+	const max = 10 * time.Second
+
+	d := base * time.Duration(int64(1)<<uint(attempt-1))
+	if d <= 0 || d > max {
+		d = max
+	}
+	jitter := time.Duration(rand.Int63n(int64(d)/2 + 1))
+	return d/2 + jitter
+}
+
+// defaultRetryOn is the default RetryOn: retry connection errors, 5xx
+// responses and 429 (Too Many Requests).
+func defaultRetryOn(res *http.Response, err error) bool {
+	if err == nil {
+		return false
+	}
+	if herr, ok := err.(*HTTPError); ok {
+		return herr.StatusCode == http.StatusTooManyRequests ||
+			(herr.StatusCode >= 500 && herr.StatusCode <= 599)
+	}
+	if res != nil {
+		return res.StatusCode == http.StatusTooManyRequests ||
+			(res.StatusCode >= 500 && res.StatusCode <= 599)
+	}
+	// No structured status to inspect: treat as a connection-level error.
+	return true
+}
+
+// parseRetryAfter parses a Retry-After header value (either a number of
+// seconds or an HTTP date) and returns the corresponding delay, or 0 if it
+// cannot be parsed.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// rateLimiter is a simple token bucket used to cap outgoing requests to a
+// configured RequestsPerSecond / Burst.
+type rateLimiter struct {
+	mu         sync.Mutex
+	rate       float64
+	burst      float64
+	tokens     float64
+	lastRefill time.Time
+}
+
+// newRateLimiter returns a rateLimiter for rps requests/second, or nil when
+// rps is not strictly positive (i.e. rate limiting is disabled).
+func newRateLimiter(rps float64, burst int) *rateLimiter {
+	if rps <= 0 {
+		return nil
+	}
+	b := float64(burst)
+	if b <= 0 {
+		b = rps
+	}
+	if b < 1 {
+		b = 1
+	}
+	return &rateLimiter{rate: rps, burst: b, tokens: b, lastRefill: time.Now()}
+}
+
+// wait blocks until a token is available or ctx is done. A nil receiver is
+// a no-op, so callers can unconditionally invoke it.
+func (r *rateLimiter) wait(ctx context.Context) error {
+	if r == nil {
+		return nil
+	}
+	for {
+		r.mu.Lock()
+		now := time.Now()
+		r.tokens = math.Min(r.burst, r.tokens+now.Sub(r.lastRefill).Seconds()*r.rate)
+		r.lastRefill = now
+		if r.tokens >= 1 {
+			r.tokens--
+			r.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - r.tokens) / r.rate * float64(time.Second))
+		r.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
 // log Internal logger function
 func (c *HTTPClient) log(level int, format string, args ...interface{}) {
 	if level > c.LoggerLevel {
@@ -0,0 +1,161 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	cases := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{"empty", "", 0},
+		{"seconds", "5", 5 * time.Second},
+		{"invalid", "not-a-duration", 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := parseRetryAfter(tc.value); got != tc.want {
+				t.Errorf("parseRetryAfter(%q) = %v, want %v", tc.value, got, tc.want)
+			}
+		})
+	}
+
+	// An HTTP-date a few seconds in the future should resolve to a
+	// roughly-matching positive duration rather than 0.
+	future := time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)
+	got := parseRetryAfter(future)
+	if got <= 0 || got > 11*time.Second {
+		t.Errorf("parseRetryAfter(%q) = %v, want a duration close to 10s", future, got)
+	}
+}
+
+func TestDefaultRetryBackoffGrowsAndCaps(t *testing.T) {
+	var last time.Duration
+	for attempt := 1; attempt <= 10; attempt++ {
+		d := defaultRetryBackoff(attempt)
+		if d <= 0 {
+			t.Fatalf("attempt %d: backoff must be positive, got %v", attempt, d)
+		}
+		if d > 10*time.Second {
+			t.Fatalf("attempt %d: backoff %v exceeds the 10s cap", attempt, d)
+		}
+		// Below the cap (attempts 1 to 6), attempt n falls in [d/2, d] where
+		// d/2 is the previous attempt's upper bound, so it never shrinks.
+		if attempt <= 6 && d < last {
+			t.Fatalf("attempt %d: backoff %v is shorter than the previous %v", attempt, d, last)
+		}
+		last = d
+	}
+	if last <= 0 {
+		t.Fatalf("final backoff should still be positive, got %v", last)
+	}
+}
+
+func TestDefaultRetryOn(t *testing.T) {
+	cases := []struct {
+		name string
+		res  *http.Response
+		err  error
+		want bool
+	}{
+		{"no error", &http.Response{StatusCode: 200}, nil, false},
+		{"connection error, no response", nil, errors.New("dial tcp: connection refused"), true},
+		{"structured 500", nil, &HTTPError{StatusCode: 500}, true},
+		{"structured 429", nil, &HTTPError{StatusCode: 429}, true},
+		{"structured 404", nil, &HTTPError{StatusCode: 404}, false},
+		{"raw response 503", &http.Response{StatusCode: 503}, errors.New("boom"), true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := defaultRetryOn(tc.res, tc.err); got != tc.want {
+				t.Errorf("defaultRetryOn(%v, %v) = %v, want %v", tc.res, tc.err, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRateLimiterNilIsNoop(t *testing.T) {
+	var r *rateLimiter
+	if err := r.wait(context.Background()); err != nil {
+		t.Fatalf("nil rateLimiter.wait() should never error, got %v", err)
+	}
+}
+
+func TestRateLimiterBurstThenThrottles(t *testing.T) {
+	r := newRateLimiter(10, 2)
+
+	// The first Burst calls should not block at all.
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if err := r.wait(context.Background()); err != nil {
+			t.Fatalf("wait() #%d: %v", i, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Fatalf("burst of 2 tokens took %v, expected near-instant", elapsed)
+	}
+
+	// The bucket is now empty: a further wait must block until refill,
+	// unless ctx is canceled first.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := r.wait(ctx); err == nil {
+		t.Fatalf("wait() on an empty bucket should have blocked past the context deadline")
+	}
+}
+
+func TestHTTPErrorIs(t *testing.T) {
+	csrf := &HTTPError{StatusCode: http.StatusForbidden, csrf: true}
+	apikey := &HTTPError{StatusCode: http.StatusForbidden, csrf: false}
+	unauthorized := &HTTPError{StatusCode: http.StatusUnauthorized}
+	notFound := &HTTPError{StatusCode: http.StatusNotFound}
+
+	if !errors.Is(csrf, ErrCSRF) {
+		t.Error("expected a CSRF-flagged 403 to match ErrCSRF")
+	}
+	if errors.Is(apikey, ErrCSRF) {
+		t.Error("a plain (non-CSRF) 403 must not match ErrCSRF")
+	}
+	if !errors.Is(unauthorized, ErrUnauthorized) {
+		t.Error("expected a 401 to match ErrUnauthorized")
+	}
+	if !errors.Is(notFound, ErrNotFound) {
+		t.Error("expected a 404 to match ErrNotFound")
+	}
+	if errors.Is(notFound, ErrUnauthorized) {
+		t.Error("a 404 must not match ErrUnauthorized")
+	}
+
+	if !IsCSRFError(csrf) || IsCSRFError(apikey) {
+		t.Error("IsCSRFError must only report true for the CSRF-flagged 403")
+	}
+	if !IsUnauthorized(unauthorized) {
+		t.Error("IsUnauthorized(unauthorized) should be true")
+	}
+	if !IsNotFound(notFound) {
+		t.Error("IsNotFound(notFound) should be true")
+	}
+}
+
+func TestHTTPErrorMessage(t *testing.T) {
+	withAPIError := &HTTPError{Status: "500 Internal Server Error", APIError: "boom"}
+	if got := withAPIError.Error(); got != "boom" {
+		t.Errorf("Error() = %q, want the decoded API error message", got)
+	}
+
+	withBody := &HTTPError{Status: "500 Internal Server Error", Body: []byte(" plain text failure \n")}
+	if got := withBody.Error(); got != "plain text failure" {
+		t.Errorf("Error() = %q, want the trimmed response body", got)
+	}
+
+	bare := &HTTPError{Status: "500 Internal Server Error"}
+	if got := bare.Error(); got != "HTTP status 500 Internal Server Error" {
+		t.Errorf("Error() = %q, want a fallback built from Status", got)
+	}
+}